@@ -0,0 +1,145 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pathtools
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// expandBraces expands shell-style brace alternations in pattern, such as
+// src/{foo,bar}/**/*.go, into the concrete patterns they represent.  Nested
+// braces, e.g. {a,{b,c}}, are expanded recursively, and a backslash-escaped
+// "{", "}" or "," is left untouched.  As in bash, zsh and
+// .dockerignore/.gitignore, a "{...}" group with no top-level comma, such as
+// the one in foo{bar}baz, has no alternatives to expand and is left as a
+// literal instead of being stripped.  If pattern contains no unescaped "{"
+// that introduces an alternation, expandBraces returns a single-element
+// slice containing pattern unchanged.
+func expandBraces(pattern string) []string {
+	start, end, found := firstAlternatingBraceGroup(pattern)
+	if !found {
+		return []string{pattern}
+	}
+
+	prefix := pattern[:start]
+	suffix := pattern[end+1:]
+
+	var expanded []string
+	for _, alt := range splitTopLevel(pattern[start+1:end], ',') {
+		expanded = append(expanded, expandBraces(prefix+alt+suffix)...)
+	}
+	return expanded
+}
+
+// firstAlternatingBraceGroup returns the start and end indexes (inclusive) of
+// the first unescaped top-level "{...}" group in pattern that has more than
+// one top-level comma-separated alternative, skipping over any earlier group
+// that doesn't since it has nothing to alternate and is left as a literal.
+func firstAlternatingBraceGroup(pattern string) (start, end int, found bool) {
+	for searchFrom := 0; ; {
+		s, e, ok := firstBraceGroup(pattern[searchFrom:])
+		if !ok {
+			return 0, 0, false
+		}
+		s, e = s+searchFrom, e+searchFrom
+
+		if len(splitTopLevel(pattern[s+1:e], ',')) > 1 {
+			return s, e, true
+		}
+		searchFrom = e + 1
+	}
+}
+
+// firstBraceGroup returns the start and end indexes (inclusive) of the first
+// unescaped top-level "{...}" group in pattern.
+func firstBraceGroup(pattern string) (start, end int, found bool) {
+	depth := 0
+	start = -1
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\' && i+1 < len(pattern):
+			i++
+		case c == '{':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 && start >= 0 {
+				return start, i, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// splitTopLevel splits s on sep, ignoring occurrences of sep nested inside a
+// "{...}" group or escaped with a backslash.
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth := 0
+	last := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s):
+			i++
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[last:i])
+			last = i + 1
+		}
+	}
+	return append(parts, s[last:])
+}
+
+// globMatch is equivalent to filepath.Match, but also accepts "[!...]" as a
+// negated character class in addition to the "[^...]" syntax filepath.Match
+// natively supports, matching the convention used by bash, zsh and
+// .dockerignore/.gitignore patterns.
+func globMatch(pattern, name string) (bool, error) {
+	return filepath.Match(negateCharClasses(pattern), name)
+}
+
+// negateCharClasses rewrites "[!" to "[^" wherever it introduces a character
+// class, leaving escaped brackets alone.
+func negateCharClasses(pattern string) string {
+	if !strings.Contains(pattern, "[!") {
+		return pattern
+	}
+
+	var b strings.Builder
+	b.Grow(len(pattern))
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if c == '\\' && i+1 < len(pattern) {
+			b.WriteByte(c)
+			b.WriteByte(pattern[i+1])
+			i++
+			continue
+		}
+		if c == '[' && i+1 < len(pattern) && pattern[i+1] == '!' {
+			b.WriteString("[^")
+			i++
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,105 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pathtools
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestExpandBraces(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		want    []string
+	}{
+		{
+			name:    "no braces",
+			pattern: "src/*.go",
+			want:    []string{"src/*.go"},
+		},
+		{
+			name:    "simple alternation",
+			pattern: "src/{foo,bar}/*.go",
+			want:    []string{"src/foo/*.go", "src/bar/*.go"},
+		},
+		{
+			name:    "nested braces",
+			pattern: "src/{foo,{bar,baz}}/*.go",
+			want:    []string{"src/foo/*.go", "src/bar/*.go", "src/baz/*.go"},
+		},
+		{
+			name:    "escaped brace is left untouched",
+			pattern: `src/\{foo,bar\}/*.go`,
+			want:    []string{`src/\{foo,bar\}/*.go`},
+		},
+		{
+			name:    "escaped comma keeps its alternative whole",
+			pattern: `src/{foo\,bar,baz}/*.go`,
+			want:    []string{`src/foo\,bar/*.go`, `src/baz/*.go`},
+		},
+		{
+			name:    "multiple groups",
+			pattern: "{a,b}/{c,d}",
+			want:    []string{"a/c", "a/d", "b/c", "b/d"},
+		},
+		{
+			name:    "brace group with no top-level comma is left as a literal",
+			pattern: "foo{bar}baz",
+			want:    []string{"foo{bar}baz"},
+		},
+		{
+			name:    "non-alternating group before an alternating one",
+			pattern: "a{b}c{d,e}f",
+			want:    []string{"a{b}cdf", "a{b}cef"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := expandBraces(tt.pattern)
+			sort.Strings(got)
+			want := append([]string(nil), tt.want...)
+			sort.Strings(want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("expandBraces(%q) = %v, want %v", tt.pattern, got, want)
+			}
+		})
+	}
+}
+
+func TestGlobMatchNegatedCharClass(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"[!a]bc", "abc", false},
+		{"[!a]bc", "bbc", true},
+		{"[^a]bc", "bbc", true},
+		{`\[!a\]bc`, "[!a]bc", true},
+	}
+
+	for _, tt := range tests {
+		got, err := globMatch(tt.pattern, tt.name)
+		if err != nil {
+			t.Fatalf("globMatch(%q, %q) returned error: %v", tt.pattern, tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pathtools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// DirCache memoizes directory listings across multiple Glob calls in the same
+// build invocation, so that a build with many ** patterns does not re-read
+// the same directories repeatedly.  A single DirCache is safe to share
+// across concurrent Glob calls.
+type DirCache interface {
+	// ReadDir returns the, possibly cached, directory entries of path.
+	ReadDir(path string) ([]os.FileInfo, error)
+
+	// Invalidate drops any cached listing for path, for callers that know
+	// path has changed since it was last read.
+	Invalidate(path string)
+}
+
+// NewDirCache returns a DirCache that serves ReadDir calls from fs, caching
+// each directory's listing until Invalidate is called for it.
+func NewDirCache(fs FS) DirCache {
+	return &dirCache{fs: fs, entries: make(map[string][]os.FileInfo)}
+}
+
+type dirCache struct {
+	fs      FS
+	mu      sync.Mutex
+	entries map[string][]os.FileInfo
+}
+
+func (c *dirCache) ReadDir(path string) ([]os.FileInfo, error) {
+	c.mu.Lock()
+	entries, ok := c.entries[path]
+	c.mu.Unlock()
+	if ok {
+		return entries, nil
+	}
+
+	entries, err := c.fs.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[path] = entries
+	c.mu.Unlock()
+
+	return entries, nil
+}
+
+func (c *dirCache) Invalidate(path string) {
+	c.mu.Lock()
+	delete(c.entries, path)
+	c.mu.Unlock()
+}
+
+// symlinkFollower lets concurrentWalkAllDirs resolve symlinked directory
+// entries while walking, and identifies every directory in the walk (whether
+// reached through a symlink or not) so that a symlink pointing back at one of
+// its own ancestors can be recognized as a real cycle rather than a harmless
+// repeat visit to a directory reached by two different paths -- for example
+// two sibling symlinks in an Android/Chromium vendor layout that both point
+// at the same shared prebuilt tree, which is not a cycle.
+type symlinkFollower struct {
+	// shouldFollow reports whether entry, a symlink at path, is eligible to
+	// be followed under the caller's GlobOptions.
+	shouldFollow func(path string, entry os.FileInfo) (bool, error)
+
+	// dirKey resolves path, following a trailing symlink, and returns a
+	// value that uniquely identifies the directory it refers to.  isDir is
+	// false if path does not refer to a directory.
+	dirKey func(path string) (key interface{}, isDir bool, err error)
+}
+
+// maxGlobWorkers bounds the number of directories concurrentWalkAllDirs will
+// read at once.
+var maxGlobWorkers = runtime.NumCPU()
+
+// concurrentWalkAllDirs returns dir and every directory under it, reading
+// sibling subtrees concurrently on a worker pool bounded by maxGlobWorkers.
+// readDir is a parameter rather than a Globber method so that callers can
+// route it through a DirCache.  The returned slice is sorted so that the
+// result is deterministic despite the concurrent traversal, which matters
+// since it ends up in Blueprint's generated dep files.
+//
+// follower, if non-nil, is consulted for every entry that is itself a
+// symlink and reports whether the walk should descend into it; a nil
+// follower leaves symlinked directories unvisited, matching the historical
+// behavior of this package.  When follower is non-nil, each branch of the
+// walk tracks the chain of directory identities from dir down to its current
+// position, so only a symlink that resolves back to one of its own ancestors
+// is reported as GlobSymlinkCycleErr.
+func concurrentWalkAllDirs(ctx context.Context, dir string, readDir func(string) ([]os.FileInfo, error),
+	follower *symlinkFollower) (dirs []string, err error) {
+	var (
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, maxGlobWorkers)
+		mu       sync.Mutex
+		firstErr error
+	)
+
+	setErr := func(e error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = e
+		}
+		mu.Unlock()
+	}
+
+	var walk func(d string, ancestors []interface{})
+	walk = func(d string, ancestors []interface{}) {
+		defer wg.Done()
+
+		select {
+		case <-ctx.Done():
+			setErr(ctx.Err())
+			return
+		default:
+		}
+
+		entries, err := readDir(d)
+		if err != nil {
+			setErr(err)
+			return
+		}
+
+		mu.Lock()
+		dirs = append(dirs, d)
+		mu.Unlock()
+
+		for _, entry := range entries {
+			sub := filepath.Join(d, entry.Name())
+
+			isDir := entry.Mode().IsDir()
+			childAncestors := ancestors
+
+			if follower != nil && !isDir && entry.Mode()&os.ModeSymlink != 0 {
+				follow, err := follower.shouldFollow(sub, entry)
+				if err != nil {
+					setErr(err)
+					return
+				}
+				if follow {
+					key, keyIsDir, err := follower.dirKey(sub)
+					if err != nil {
+						setErr(err)
+						return
+					}
+					if keyIsDir {
+						for _, a := range ancestors {
+							if a == key {
+								setErr(GlobSymlinkCycleErr)
+								return
+							}
+						}
+						isDir = true
+						childAncestors = append(append([]interface{}{}, ancestors...), key)
+					}
+				}
+			} else if follower != nil && isDir {
+				key, _, err := follower.dirKey(sub)
+				if err != nil {
+					setErr(err)
+					return
+				}
+				childAncestors = append(append([]interface{}{}, ancestors...), key)
+			}
+
+			if !isDir {
+				continue
+			}
+
+			wg.Add(1)
+			select {
+			case sem <- struct{}{}:
+				go func() {
+					defer func() { <-sem }()
+					walk(sub, childAncestors)
+				}()
+			default:
+				// The worker pool is full, walk this subtree inline rather
+				// than blocking on a free slot.
+				walk(sub, childAncestors)
+			}
+		}
+	}
+
+	var rootAncestors []interface{}
+	if follower != nil {
+		if key, isDir, err := follower.dirKey(dir); err == nil && isDir {
+			rootAncestors = []interface{}{key}
+		}
+	}
+
+	wg.Add(1)
+	walk(dir, rootAncestors)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	sort.Strings(dirs)
+
+	return dirs, nil
+}
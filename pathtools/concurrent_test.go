@@ -0,0 +1,81 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pathtools
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func newConcurrentWalkTestFS() *MemFS {
+	fs := NewMemFS()
+	fs.WriteFile("a/b/file1.txt", nil)
+	fs.WriteFile("a/c/file2.txt", nil)
+	fs.WriteFile("a/c/d/file3.txt", nil)
+	fs.WriteFile("a/e/file4.txt", nil)
+	return fs
+}
+
+func TestConcurrentWalkAllDirsDeterministic(t *testing.T) {
+	fs := newConcurrentWalkTestFS()
+	want := []string{"a", "a/b", "a/c", "a/c/d", "a/e"}
+
+	// maxGlobWorkers controls how many subtrees are walked concurrently;
+	// forcing it below the number of subdirectories here ensures the
+	// inline-fallback path runs too, not just the goroutine path.
+	oldMaxGlobWorkers := maxGlobWorkers
+	maxGlobWorkers = 2
+	defer func() { maxGlobWorkers = oldMaxGlobWorkers }()
+
+	for i := 0; i < 20; i++ {
+		dirs, err := concurrentWalkAllDirs(context.Background(), "a", fs.ReadDir, nil)
+		if err != nil {
+			t.Fatalf("concurrentWalkAllDirs returned error: %v", err)
+		}
+		if !reflect.DeepEqual(dirs, want) {
+			t.Fatalf("concurrentWalkAllDirs = %v, want %v", dirs, want)
+		}
+	}
+}
+
+func TestConcurrentWalkAllDirsPropagatesError(t *testing.T) {
+	fs := newConcurrentWalkTestFS()
+
+	_, err := concurrentWalkAllDirs(context.Background(), "does/not/exist", fs.ReadDir, nil)
+	if err == nil {
+		t.Fatal("concurrentWalkAllDirs with a nonexistent root returned no error")
+	}
+}
+
+func TestGlobberGlobRecursiveOverMemFS(t *testing.T) {
+	fs := newConcurrentWalkTestFS()
+	g := NewGlobber(fs)
+
+	matches, dirs, err := g.GlobWithExcludes("a/**/*.txt", nil)
+	if err != nil {
+		t.Fatalf("GlobWithExcludes returned error: %v", err)
+	}
+
+	wantMatches := []string{"a/b/file1.txt", "a/c/d/file3.txt", "a/c/file2.txt", "a/e/file4.txt"}
+	if !reflect.DeepEqual(matches, wantMatches) {
+		t.Errorf("matches = %v, want %v", matches, wantMatches)
+	}
+
+	wantDirs := []string{"a", "a/b", "a/c", "a/c/d", "a/e"}
+	if !reflect.DeepEqual(dirs, wantDirs) {
+		t.Errorf("dirs = %v, want %v", dirs, wantDirs)
+	}
+}
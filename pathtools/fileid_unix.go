@@ -0,0 +1,35 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package pathtools
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileKey returns the device and inode number of info, used to detect
+// symlink cycles when GlobOptions.FollowSymlinks is set.  ok is false if
+// info.Sys() did not come from the real filesystem (for example a MemFS),
+// in which case the caller falls back to a resolved-path based key.
+func fileKey(info os.FileInfo) (key interface{}, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil, false
+	}
+	return [2]uint64{uint64(st.Dev), uint64(st.Ino)}, true
+}
@@ -0,0 +1,27 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+// +build windows
+
+package pathtools
+
+import "os"
+
+// fileKey has no portable way to get a unique file identifier from
+// os.FileInfo on windows, so callers fall back to a resolved-path based key
+// for symlink cycle detection.
+func fileKey(info os.FileInfo) (key interface{}, ok bool) {
+	return nil, false
+}
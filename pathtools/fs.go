@@ -0,0 +1,203 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pathtools
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FS is the minimal filesystem interface required to perform globbing.  It is
+// modeled after the os and io/ioutil functions that Glob used directly before
+// it was made pluggable, so that globbing can be performed against
+// filesystems other than the real one on disk -- in-memory trees, zip/tar
+// overlays, or remote/virtual sources provided by out-of-tree Blueprint
+// drivers.
+type FS interface {
+	// Stat returns the FileInfo for path, following symlinks.
+	Stat(path string) (os.FileInfo, error)
+
+	// Lstat returns the FileInfo for path, without following a trailing
+	// symlink.
+	Lstat(path string) (os.FileInfo, error)
+
+	// ReadDir returns the directory entries of path, sorted by filename, in
+	// the same manner as ioutil.ReadDir.
+	ReadDir(path string) ([]os.FileInfo, error)
+
+	// Open opens the named file for reading.
+	Open(path string) (io.ReadCloser, error)
+}
+
+// GlobFS is an optional interface an FS may implement to provide its own
+// accelerated implementation of globbing, bypassing the generic walk-based
+// implementation in this package.  This is useful for filesystems that can
+// answer a glob more efficiently than a naive walk, for example one backed by
+// an index or a remote API.
+type GlobFS interface {
+	FS
+
+	// Glob returns the list of files under the FS that match pattern but do
+	// not match excludes, along with the list of directories that were
+	// searched to construct the file list, using the same semantics as the
+	// package-level GlobWithExcludes.
+	Glob(pattern string, excludes []string) (matches, dirs []string, err error)
+}
+
+// SymlinkFS is an optional interface an FS may implement to support
+// GlobOptions.FollowSymlinks and GlobOptions.FollowPaths, which need to
+// resolve a symlink to its target before deciding whether to descend into
+// it.  FollowSymlinks and FollowPaths have no effect on an FS that does not
+// implement SymlinkFS.
+type SymlinkFS interface {
+	FS
+
+	// EvalSymlinks returns path with any symlinks resolved, in the same
+	// manner as filepath.EvalSymlinks.
+	EvalSymlinks(path string) (string, error)
+}
+
+// OsFS is the FS used by the package-level Glob, GlobWithExcludes, and
+// GlobWithDepFile functions.  It is backed by the real filesystem using the
+// os and io/ioutil packages, and preserves the behavior Glob had before FS
+// was introduced.
+var OsFS FS = osFS{}
+
+type osFS struct{}
+
+func (osFS) Stat(path string) (os.FileInfo, error) { return os.Stat(path) }
+
+func (osFS) Lstat(path string) (os.FileInfo, error) { return os.Lstat(path) }
+
+func (osFS) ReadDir(path string) ([]os.FileInfo, error) { return ioutil.ReadDir(path) }
+
+func (osFS) Open(path string) (io.ReadCloser, error) { return os.Open(path) }
+
+func (osFS) EvalSymlinks(path string) (string, error) { return filepath.EvalSymlinks(path) }
+
+// MemFS is an in-memory FS implementation intended for use in tests, so that
+// globbing logic can be exercised without touching disk.  Create one with
+// NewMemFS and populate it with MkDirs and WriteFile; a zero-value MemFS has
+// no root directory and cannot be populated.
+type MemFS struct {
+	files map[string]*memFile
+}
+
+type memFile struct {
+	dir     bool
+	data    []byte
+	modTime time.Time
+}
+
+// NewMemFS returns an empty MemFS rooted at "." that includes an empty root
+// directory.
+func NewMemFS() *MemFS {
+	fs := &MemFS{files: make(map[string]*memFile)}
+	fs.files["."] = &memFile{dir: true}
+	return fs
+}
+
+func memClean(path string) string {
+	return filepath.Clean(path)
+}
+
+// MkDirs adds path, and any missing ancestor directories, to the MemFS as
+// directories.
+func (m *MemFS) MkDirs(path string) {
+	path = memClean(path)
+	for path != "." {
+		if _, exists := m.files[path]; exists {
+			return
+		}
+		m.files[path] = &memFile{dir: true}
+		path = filepath.Dir(path)
+	}
+}
+
+// WriteFile adds path to the MemFS as a regular file with the given
+// contents, creating any missing ancestor directories.
+func (m *MemFS) WriteFile(path string, data []byte) {
+	path = memClean(path)
+	m.MkDirs(filepath.Dir(path))
+	m.files[path] = &memFile{data: data, modTime: time.Now()}
+}
+
+func (m *MemFS) get(path string) (*memFile, bool) {
+	f, ok := m.files[memClean(path)]
+	return f, ok
+}
+
+func (m *MemFS) Stat(path string) (os.FileInfo, error) {
+	f, ok := m.get(path)
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: path, Err: os.ErrNotExist}
+	}
+	return memFileInfo{name: filepath.Base(memClean(path)), f: f}, nil
+}
+
+func (m *MemFS) Lstat(path string) (os.FileInfo, error) {
+	// MemFS does not model symlinks, so Lstat behaves like Stat.
+	return m.Stat(path)
+}
+
+func (m *MemFS) ReadDir(path string) ([]os.FileInfo, error) {
+	path = memClean(path)
+	dir, ok := m.get(path)
+	if !ok || !dir.dir {
+		return nil, &os.PathError{Op: "readdir", Path: path, Err: os.ErrNotExist}
+	}
+
+	var infos []os.FileInfo
+	for p, f := range m.files {
+		if p == path || filepath.Dir(p) != path {
+			continue
+		}
+		infos = append(infos, memFileInfo{name: filepath.Base(p), f: f})
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+
+	return infos, nil
+}
+
+func (m *MemFS) Open(path string) (io.ReadCloser, error) {
+	f, ok := m.get(path)
+	if !ok || f.dir {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+	return ioutil.NopCloser(strings.NewReader(string(f.data))), nil
+}
+
+type memFileInfo struct {
+	name string
+	f    *memFile
+}
+
+func (i memFileInfo) Name() string { return i.name }
+func (i memFileInfo) Size() int64  { return int64(len(i.f.data)) }
+func (i memFileInfo) Mode() os.FileMode {
+	if i.f.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+func (i memFileInfo) ModTime() time.Time { return i.f.modTime }
+func (i memFileInfo) IsDir() bool        { return i.f.dir }
+func (i memFileInfo) Sys() interface{}   { return nil }
@@ -15,11 +15,13 @@
 package pathtools
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/google/blueprint/deptools"
@@ -28,6 +30,25 @@ import (
 var GlobMultipleRecursiveErr = errors.New("pattern contains multiple **")
 var GlobLastRecursiveErr = errors.New("pattern ** as last path element")
 
+// Globber globs patterns against a particular FS.  The package-level Glob,
+// GlobWithExcludes, and GlobWithDepFile functions are backed by a default
+// Globber that globs the real filesystem via OsFS.  Callers that need to
+// glob over another filesystem -- an in-memory tree built for tests, a
+// zip/tar overlay, or a remote/virtual source provided by an out-of-tree
+// Blueprint driver -- can construct their own with NewGlobber.
+type Globber struct {
+	fs FS
+}
+
+// NewGlobber returns a Globber that globs against fs.  If fs implements
+// GlobFS, its Glob method is used directly instead of the generic
+// walk-based implementation in this package.
+func NewGlobber(fs FS) *Globber {
+	return &Globber{fs: fs}
+}
+
+var defaultGlobber = NewGlobber(OsFS)
+
 // Glob returns the list of files that match the given pattern along with the
 // list of directories that were searched to construct the file list.
 // The supported glob patterns are equivalent to filepath.Glob, with an
@@ -39,7 +60,7 @@ var GlobLastRecursiveErr = errors.New("pattern ** as last path element")
 // should be used instead, as they will automatically set up dependencies
 // to rerun the primary builder when the list of matching files changes.
 func Glob(pattern string) (matches, dirs []string, err error) {
-	return GlobWithExcludes(pattern, nil)
+	return defaultGlobber.Glob(pattern)
 }
 
 // GlobWithExcludes returns the list of files that match the given pattern but
@@ -53,16 +74,71 @@ func Glob(pattern string) (matches, dirs []string, err error) {
 // should be used instead, as they will automatically set up dependencies
 // to rerun the primary builder when the list of matching files changes.
 func GlobWithExcludes(pattern string, excludes []string) (matches, dirs []string, err error) {
-	if filepath.Base(pattern) == "**" {
-		return nil, nil, GlobLastRecursiveErr
-	} else {
-		matches, dirs, err = glob(pattern, false)
+	return defaultGlobber.GlobWithExcludes(pattern, excludes)
+}
+
+// Glob is equivalent to the package-level Glob function, but searches g's FS
+// instead of the real filesystem.
+func (g *Globber) Glob(pattern string) (matches, dirs []string, err error) {
+	return g.GlobWithExcludes(pattern, nil)
+}
+
+// GlobWithExcludes is equivalent to the package-level GlobWithExcludes
+// function, but searches g's FS instead of the real filesystem.
+func (g *Globber) GlobWithExcludes(pattern string, excludes []string) (matches, dirs []string, err error) {
+	return g.GlobWithContext(context.Background(), pattern, excludes, nil)
+}
+
+// GlobWithContext is equivalent to GlobWithExcludes, but makes cancellation
+// explicit via ctx and, when cache is non-nil, reads directories through it
+// instead of g's FS directly, so a caller globbing many ** patterns in the
+// same build invocation doesn't re-read the same directories over and over.
+// Glob, GlobWithExcludes and GlobWithDepFile are thin wrappers around
+// GlobWithContext with a background context and no cache.
+func (g *Globber) GlobWithContext(ctx context.Context, pattern string, excludes []string, cache DirCache) (matches, dirs []string, err error) {
+	return g.globAll(ctx, pattern, excludes, cache, GlobOptions{})
+}
+
+// globAll is the shared implementation behind GlobWithContext and GlobWith.
+func (g *Globber) globAll(ctx context.Context, pattern string, excludes []string, cache DirCache, opts GlobOptions) (matches, dirs []string, err error) {
+	if gfs, ok := g.fs.(GlobFS); ok {
+		matches, dirs, err = gfs.Glob(pattern, excludes)
+		return matches, dirs, err
 	}
 
-	if err != nil {
-		return nil, nil, err
+	// A pattern containing brace alternations, e.g. src/{foo,bar}/*.go,
+	// expands to more than one concrete pattern; glob each one and union the
+	// results, deduplicating matches and merging the searched-dir lists.
+	matchSet := make(map[string]bool)
+	dirSet := make(map[string]bool)
+
+	for _, p := range expandBraces(pattern) {
+		if filepath.Base(p) == "**" {
+			return nil, nil, GlobLastRecursiveErr
+		}
+
+		m, d, err := g.glob(ctx, p, false, cache, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, match := range m {
+			matchSet[match] = true
+		}
+		for _, dir := range d {
+			dirSet[dir] = true
+		}
 	}
 
+	for m := range matchSet {
+		matches = append(matches, m)
+	}
+	for d := range dirSet {
+		dirs = append(dirs, d)
+	}
+	sort.Strings(matches)
+	sort.Strings(dirs)
+
 	matches, err = filterExcludes(matches, excludes)
 	if err != nil {
 		return nil, nil, err
@@ -71,14 +147,44 @@ func GlobWithExcludes(pattern string, excludes []string) (matches, dirs []string
 	return matches, dirs, nil
 }
 
+// GlobWithContext is equivalent to the package-level GlobWithExcludes
+// function, but makes cancellation explicit via ctx and, when cache is
+// non-nil, reuses directory listings across calls instead of re-reading them
+// from disk.
+func GlobWithContext(ctx context.Context, pattern string, excludes []string, cache DirCache) (matches, dirs []string, err error) {
+	return defaultGlobber.GlobWithContext(ctx, pattern, excludes, cache)
+}
+
+// GlobWithDepFile finds all files that match g's FS against glob.  It
+// compares the list of files against the contents of fileListFile, and
+// rewrites fileListFile if it has changed.  It also writes all of the
+// directories it traversed as a dependency on fileListFile to depFile.
+//
+// The format of glob is either path/*.ext for a single directory glob, or
+// path/**/*.ext for a recursive glob.
+//
+// Returns a list of file paths, and an error.
+func (g *Globber) GlobWithDepFile(glob, fileListFile, depFile string, excludes []string) (files []string, err error) {
+	files, dirs, err := g.GlobWithExcludes(glob, excludes)
+	if err != nil {
+		return nil, err
+	}
+
+	fileList := strings.Join(files, "\n") + "\n"
+
+	WriteFileIfChanged(fileListFile, []byte(fileList), 0666)
+	deptools.WriteDepFile(depFile, fileListFile, dirs)
+
+	return
+}
+
 // glob is a recursive helper function to handle globbing each level of the pattern individually,
 // allowing searched directories to be tracked.  Also handles the recursive glob pattern, **.
-func glob(pattern string, hasRecursive bool) (matches, dirs []string, err error) {
+func (g *Globber) glob(ctx context.Context, pattern string, hasRecursive bool, cache DirCache, opts GlobOptions) (matches, dirs []string, err error) {
 	if !isWild(pattern) {
 		// If there are no wilds in the pattern, check whether the file exists or not.
-		// Uses filepath.Glob instead of manually statting to get consistent results.
 		pattern = filepath.Clean(pattern)
-		matches, err = filepath.Glob(pattern)
+		matches, err = g.fsGlobLiteral(pattern)
 		if err != nil {
 			return matches, dirs, err
 		}
@@ -89,7 +195,7 @@ func glob(pattern string, hasRecursive bool) (matches, dirs []string, err error)
 			var matchDirs []string
 			for len(matchDirs) == 0 {
 				pattern, _ = saneSplit(pattern)
-				matchDirs, err = filepath.Glob(pattern)
+				matchDirs, err = g.fsGlobLiteral(pattern)
 				if err != nil {
 					return matches, dirs, err
 				}
@@ -108,26 +214,26 @@ func glob(pattern string, hasRecursive bool) (matches, dirs []string, err error)
 		hasRecursive = true
 	}
 
-	dirMatches, dirs, err := glob(dir, hasRecursive)
+	dirMatches, dirs, err := g.glob(ctx, dir, hasRecursive, cache, opts)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	for _, m := range dirMatches {
-		info, err := os.Stat(m)
+		info, err := g.fs.Stat(m)
 		if err != nil {
 			return nil, nil, fmt.Errorf("unexpected error after glob: %s", err)
 		}
 		if info.IsDir() {
 			if file == "**" {
-				recurseDirs, err := walkAllDirs(m)
+				recurseDirs, err := g.walkAllDirs(ctx, m, cache, opts)
 				if err != nil {
 					return nil, nil, err
 				}
 				matches = append(matches, recurseDirs...)
 			} else {
 				dirs = append(dirs, m)
-				newMatches, err := filepath.Glob(filepath.Join(m, file))
+				newMatches, err := g.fsGlobDir(m, file, cache)
 				if err != nil {
 					return nil, nil, err
 				}
@@ -139,6 +245,46 @@ func glob(pattern string, hasRecursive bool) (matches, dirs []string, err error)
 	return matches, dirs, nil
 }
 
+// fsGlobLiteral reports whether the non-wild, cleaned pattern exists on g's
+// FS, mirroring the semantics filepath.Glob provides for a pattern with no
+// glob metacharacters.
+func (g *Globber) fsGlobLiteral(pattern string) (matches []string, err error) {
+	if _, err := g.fs.Lstat(pattern); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return []string{pattern}, nil
+}
+
+// fsGlobDir matches file, a single path element that may contain glob
+// metacharacters, against the entries of dir, read through cache if it is
+// non-nil or from g's FS otherwise.
+func (g *Globber) fsGlobDir(dir, file string, cache DirCache) (matches []string, err error) {
+	entries, err := g.readDir(dir, cache)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		match, err := globMatch(file, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			matches = append(matches, filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	sort.Strings(matches)
+
+	return matches, nil
+}
+
 // Faster version of dir, file := filepath.Dir(path), filepath.File(path) with no allocations
 // Similar to filepath.Split, but returns "." if dir is empty and trims trailing slash if dir is
 // not "/".  Returns ".", "" if path is "."
@@ -159,82 +305,55 @@ func saneSplit(path string) (dir, file string) {
 }
 
 func isWild(pattern string) bool {
-	return strings.ContainsAny(pattern, "*?[")
+	return strings.ContainsAny(pattern, "*?[{")
 }
 
-// Returns a list of all directories under dir
-func walkAllDirs(dir string) (dirs []string, err error) {
-	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		if info.Mode().IsDir() {
-			dirs = append(dirs, path)
-		}
-		return nil
-	})
+// walkAllDirs returns a sorted list of dir and all directories under it,
+// walking subtrees concurrently and reading each directory through cache if
+// it is non-nil or g's FS otherwise.
+func (g *Globber) walkAllDirs(ctx context.Context, dir string, cache DirCache, opts GlobOptions) (dirs []string, err error) {
+	readDir := g.fs.ReadDir
+	if cache != nil {
+		readDir = cache.ReadDir
+	}
+	return concurrentWalkAllDirs(ctx, dir, readDir, g.symlinkFollower(opts))
+}
 
-	return dirs, err
+// readDir reads dir through cache if it is non-nil, or from g's FS otherwise.
+func (g *Globber) readDir(dir string, cache DirCache) ([]os.FileInfo, error) {
+	if cache != nil {
+		return cache.ReadDir(dir)
+	}
+	return g.fs.ReadDir(dir)
 }
 
 // Filters the strings in matches based on the glob patterns in excludes.  Hierarchical (a/*) and
-// recursive (**) glob patterns are supported.
+// recursive (**) glob patterns are supported.  excludes is parsed once into a PatternMatcher rather
+// than being re-parsed for every file in matches.
 func filterExcludes(matches []string, excludes []string) ([]string, error) {
 	if len(excludes) == 0 {
 		return matches, nil
 	}
 
+	pm := NewPatternMatcher(excludes)
+
 	var ret []string
-matchLoop:
 	for _, m := range matches {
-		for _, e := range excludes {
-			exclude, err := match(e, m)
-			if err != nil {
-				return nil, err
-			}
-			if exclude {
-				continue matchLoop
-			}
+		exclude, err := pm.Matches(m)
+		if err != nil {
+			return nil, err
+		}
+		if !exclude {
+			ret = append(ret, m)
 		}
-		ret = append(ret, m)
 	}
 
 	return ret, nil
 }
 
-// match returns true if name matches pattern using the same rules as filepath.Match, but supporting
-// hierarchical patterns (a/*) and recursive globs (**).
-func match(pattern, name string) (bool, error) {
-	if filepath.Base(pattern) == "**" {
-		return false, GlobLastRecursiveErr
-	}
-
-	for {
-		var patternFile, nameFile string
-		pattern, patternFile = saneSplit(pattern)
-		name, nameFile = saneSplit(name)
-
-		if patternFile == "**" {
-			return matchPrefix(pattern, filepath.Join(name, nameFile))
-		}
-
-		if nameFile == "" && patternFile == "" {
-			return true, nil
-		} else if nameFile == "" || patternFile == "" {
-			return false, nil
-		}
-
-		match, err := filepath.Match(patternFile, nameFile)
-		if err != nil || !match {
-			return match, err
-		}
-	}
-}
-
 // matchPrefix returns true if the beginning of name matches pattern using the same rules as
 // filepath.Match, but supporting hierarchical patterns (a/*).  Recursive globs (**) are not
-// supported, they should have been handled in match().
+// supported, they should have been handled by the caller.
 func matchPrefix(pattern, name string) (bool, error) {
 	if len(pattern) > 0 && pattern[0] == '/' {
 		if len(name) > 0 && name[0] == '/' {
@@ -267,7 +386,7 @@ func matchPrefix(pattern, name string) (bool, error) {
 			return false, nil
 		}
 
-		match, err := filepath.Match(patternElem, nameElem)
+		match, err := globMatch(patternElem, nameElem)
 		if err != nil || !match {
 			return match, err
 		}
@@ -307,8 +426,9 @@ func GlobPatternList(patterns []string, prefix string) (globedList []string, dep
 }
 
 // IsGlob returns true if the pattern contains any glob characters (*, ?, or [).
+// A "{", which may start a brace alternation, also counts as a glob character.
 func IsGlob(pattern string) bool {
-	return strings.IndexAny(pattern, "*?[") >= 0
+	return strings.IndexAny(pattern, "*?[{") >= 0
 }
 
 // HasGlob returns true if any string in the list contains any glob characters (*, ?, or [).
@@ -335,17 +455,7 @@ func HasGlob(in []string) bool {
 // should be used instead, as they will automatically set up dependencies
 // to rerun the primary builder when the list of matching files changes.
 func GlobWithDepFile(glob, fileListFile, depFile string, excludes []string) (files []string, err error) {
-	files, dirs, err := GlobWithExcludes(glob, excludes)
-	if err != nil {
-		return nil, err
-	}
-
-	fileList := strings.Join(files, "\n") + "\n"
-
-	WriteFileIfChanged(fileListFile, []byte(fileList), 0666)
-	deptools.WriteDepFile(depFile, fileListFile, dirs)
-
-	return
+	return defaultGlobber.GlobWithDepFile(glob, fileListFile, depFile, excludes)
 }
 
 // WriteFileIfChanged wraps ioutil.WriteFile, but only writes the file if
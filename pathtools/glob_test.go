@@ -0,0 +1,232 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pathtools
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newGlobTestMemFS() *MemFS {
+	fs := NewMemFS()
+	fs.WriteFile("a/one.go", nil)
+	fs.WriteFile("a/two.go", nil)
+	fs.WriteFile("a/three.txt", nil)
+	return fs
+}
+
+func TestGlobberGlobPlainPatternMemFS(t *testing.T) {
+	g := NewGlobber(newGlobTestMemFS())
+
+	matches, dirs, err := g.Glob("a/*.go")
+	if err != nil {
+		t.Fatalf("Glob returned error: %v", err)
+	}
+
+	wantMatches := []string{"a/one.go", "a/two.go"}
+	if !reflect.DeepEqual(matches, wantMatches) {
+		t.Errorf("matches = %v, want %v", matches, wantMatches)
+	}
+
+	wantDirs := []string{"a"}
+	if !reflect.DeepEqual(dirs, wantDirs) {
+		t.Errorf("dirs = %v, want %v", dirs, wantDirs)
+	}
+}
+
+func TestGlobberGlobWithExcludesPlainPatternMemFS(t *testing.T) {
+	g := NewGlobber(newGlobTestMemFS())
+
+	matches, _, err := g.GlobWithExcludes("a/*.go", []string{"a/two.go"})
+	if err != nil {
+		t.Fatalf("GlobWithExcludes returned error: %v", err)
+	}
+
+	want := []string{"a/one.go"}
+	if !reflect.DeepEqual(matches, want) {
+		t.Errorf("matches = %v, want %v", matches, want)
+	}
+}
+
+func TestGlobberGlobPlainPatternOsFS(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"one.go", "two.go", "three.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	matches, _, err := defaultGlobber.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		t.Fatalf("Glob returned error: %v", err)
+	}
+
+	want := []string{filepath.Join(dir, "one.go"), filepath.Join(dir, "two.go")}
+	if !reflect.DeepEqual(matches, want) {
+		t.Errorf("matches = %v, want %v", matches, want)
+	}
+}
+
+func TestGlobNonWildMissingPatternRecordsNearestAncestorDir(t *testing.T) {
+	fs := NewMemFS()
+	fs.MkDirs("a/b")
+
+	g := NewGlobber(fs)
+
+	matches, dirs, err := g.Glob("a/b/c/missing.txt")
+	if err != nil {
+		t.Fatalf("Glob returned error: %v", err)
+	}
+	if len(matches) != 0 {
+		t.Errorf("matches = %v, want none", matches)
+	}
+
+	want := []string{"a/b"}
+	if !reflect.DeepEqual(dirs, want) {
+		t.Errorf("dirs = %v, want %v", dirs, want)
+	}
+}
+
+// stubGlobFS implements GlobFS with a canned result, to confirm that
+// Globber uses an FS's own Glob method instead of the generic walk-based
+// implementation when it is available.
+type stubGlobFS struct {
+	FS
+	gotPattern  string
+	gotExcludes []string
+	matches     []string
+	dirs        []string
+}
+
+func (s *stubGlobFS) Glob(pattern string, excludes []string) (matches, dirs []string, err error) {
+	s.gotPattern = pattern
+	s.gotExcludes = excludes
+	return s.matches, s.dirs, nil
+}
+
+func TestGlobberUsesGlobFS(t *testing.T) {
+	stub := &stubGlobFS{
+		matches: []string{"stub/match.go"},
+		dirs:    []string{"stub"},
+	}
+	g := NewGlobber(stub)
+
+	matches, dirs, err := g.GlobWithExcludes("stub/*.go", []string{"stub/skip.go"})
+	if err != nil {
+		t.Fatalf("GlobWithExcludes returned error: %v", err)
+	}
+
+	if stub.gotPattern != "stub/*.go" {
+		t.Errorf("GlobFS.Glob called with pattern %q, want %q", stub.gotPattern, "stub/*.go")
+	}
+	if !reflect.DeepEqual(stub.gotExcludes, []string{"stub/skip.go"}) {
+		t.Errorf("GlobFS.Glob called with excludes %v, want %v", stub.gotExcludes, []string{"stub/skip.go"})
+	}
+	if !reflect.DeepEqual(matches, stub.matches) {
+		t.Errorf("matches = %v, want %v", matches, stub.matches)
+	}
+	if !reflect.DeepEqual(dirs, stub.dirs) {
+		t.Errorf("dirs = %v, want %v", dirs, stub.dirs)
+	}
+}
+
+func TestGlobberFollowSymlinksIgnoredWithoutSymlinkFS(t *testing.T) {
+	// MemFS does not implement SymlinkFS, so FollowSymlinks must be silently
+	// ignored rather than erroring or panicking on the type assertion.
+	fs := newGlobTestMemFS()
+	g := NewGlobber(fs)
+
+	matches, _, err := g.GlobWith("a/**/*.go", GlobOptions{FollowSymlinks: true})
+	if err != nil {
+		t.Fatalf("GlobWith returned error: %v", err)
+	}
+
+	want := []string{"a/one.go", "a/two.go"}
+	if !reflect.DeepEqual(matches, want) {
+		t.Errorf("matches = %v, want %v", matches, want)
+	}
+}
+
+func TestGlobWithDepFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"one.go", "two.go"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fileListFile := filepath.Join(dir, "filelist")
+	depFile := filepath.Join(dir, "filelist.d")
+
+	files, err := defaultGlobber.GlobWithDepFile(filepath.Join(dir, "*.go"), fileListFile, depFile, nil)
+	if err != nil {
+		t.Fatalf("GlobWithDepFile returned error: %v", err)
+	}
+
+	want := []string{filepath.Join(dir, "one.go"), filepath.Join(dir, "two.go")}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("files = %v, want %v", files, want)
+	}
+
+	gotList, err := os.ReadFile(fileListFile)
+	if err != nil {
+		t.Fatalf("reading fileListFile: %v", err)
+	}
+	wantList := want[0] + "\n" + want[1] + "\n"
+	if string(gotList) != wantList {
+		t.Errorf("fileListFile contents = %q, want %q", gotList, wantList)
+	}
+
+	if _, err := os.Stat(depFile); err != nil {
+		t.Errorf("depFile was not written: %v", err)
+	}
+}
+
+func TestWriteFileIfChanged(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nested", "file")
+
+	if err := WriteFileIfChanged(path, []byte("first"), 0644); err != nil {
+		t.Fatalf("WriteFileIfChanged returned error: %v", err)
+	}
+	info1, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat after first write: %v", err)
+	}
+
+	if err := WriteFileIfChanged(path, []byte("first"), 0644); err != nil {
+		t.Fatalf("WriteFileIfChanged returned error: %v", err)
+	}
+	info2, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat after identical write: %v", err)
+	}
+	if info1.ModTime() != info2.ModTime() {
+		t.Errorf("WriteFileIfChanged rewrote a file whose contents were unchanged")
+	}
+
+	if err := WriteFileIfChanged(path, []byte("second"), 0644); err != nil {
+		t.Fatalf("WriteFileIfChanged returned error: %v", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading file after change: %v", err)
+	}
+	if string(got) != "second" {
+		t.Errorf("file contents = %q, want %q", got, "second")
+	}
+}
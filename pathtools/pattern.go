@@ -0,0 +1,152 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pathtools
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// PatternMatcher matches paths against a list of include/exclude patterns
+// that is parsed once at construction time, so that a large exclude list
+// isn't re-split and re-parsed for every file checked against it.
+//
+// Patterns are evaluated in order, and a pattern may be prefixed with "!" to
+// negate a match made by an earlier pattern, mirroring .dockerignore and
+// .gitignore evaluation order: the last pattern that matches a path wins.
+type PatternMatcher struct {
+	patterns []compiledPattern
+}
+
+type compiledPattern struct {
+	negate bool
+	elems  []string
+}
+
+// NewPatternMatcher precompiles patterns into a PatternMatcher.  Each pattern
+// may be prefixed with "!" to negate a match made by an earlier pattern.
+// Patterns otherwise use the same glob syntax as Glob and GlobWithExcludes,
+// including the recursive ** element.  Unlike a ** glob pattern, which is
+// rejected with GlobLastRecursiveErr when ** is the last path element, a
+// pattern ending in ** is accepted and matches every path under the prefix
+// before it -- so an exclude pattern such as "foo/**" excludes all of foo's
+// contents rather than erroring.
+func NewPatternMatcher(patterns []string) *PatternMatcher {
+	pm := &PatternMatcher{patterns: make([]compiledPattern, 0, len(patterns))}
+	for _, pattern := range patterns {
+		negate := false
+		for strings.HasPrefix(pattern, "!") {
+			negate = !negate
+			pattern = pattern[1:]
+		}
+		for _, expanded := range expandBraces(pattern) {
+			pm.patterns = append(pm.patterns, compiledPattern{
+				negate: negate,
+				elems:  splitPatternElems(expanded),
+			})
+		}
+	}
+	return pm
+}
+
+// Matches returns true if path matches the patterns the PatternMatcher was
+// constructed with, taking negation and evaluation order into account.
+func (pm *PatternMatcher) Matches(path string) (bool, error) {
+	matched := false
+	for _, p := range pm.patterns {
+		m, err := matchElems(p.elems, path)
+		if err != nil {
+			return false, err
+		}
+		if m {
+			matched = !p.negate
+		}
+	}
+	return matched, nil
+}
+
+// MatchesOrParentMatches returns true if path, or any ancestor directory of
+// path, matches the patterns the PatternMatcher was constructed with.  It
+// allows a caller walking a directory tree to prune a whole subtree as soon
+// as its root matches, rather than filtering every descendant afterwards.
+func (pm *PatternMatcher) MatchesOrParentMatches(path string) (bool, error) {
+	path = filepath.Clean(path)
+	for {
+		m, err := pm.Matches(path)
+		if err != nil {
+			return false, err
+		}
+		if m {
+			return true, nil
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return false, nil
+		}
+		path = parent
+	}
+}
+
+// splitPatternElems splits a pattern into its path elements once, so that
+// matchElems can walk them without re-parsing the pattern string on every
+// call.
+func splitPatternElems(pattern string) []string {
+	pattern = filepath.Clean(pattern)
+	if pattern == "." {
+		return nil
+	}
+	return strings.Split(pattern, string(filepath.Separator))
+}
+
+// matchElems returns true if name matches a pattern that has already been
+// split into path elements by splitPatternElems, using the same rules as
+// globMatch for each element and supporting hierarchical patterns (a/*) and
+// recursive globs (**), avoiding repeated splitting of the same pattern
+// across many calls.
+func matchElems(patternElems []string, name string) (bool, error) {
+	i := len(patternElems)
+	for {
+		var patternFile string
+		if i > 0 {
+			patternFile = patternElems[i-1]
+		}
+
+		var nameFile string
+		name, nameFile = saneSplit(name)
+
+		if patternFile == "**" {
+			prefix := "."
+			if i > 1 {
+				prefix = strings.Join(patternElems[:i-1], string(filepath.Separator))
+			}
+			return matchPrefix(prefix, filepath.Join(name, nameFile))
+		}
+
+		if nameFile == "" && patternFile == "" {
+			return true, nil
+		} else if nameFile == "" || patternFile == "" {
+			return false, nil
+		}
+
+		match, err := globMatch(patternFile, nameFile)
+		if err != nil || !match {
+			return match, err
+		}
+
+		if i > 0 {
+			i--
+		}
+	}
+}
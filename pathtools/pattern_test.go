@@ -0,0 +1,148 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pathtools
+
+import "testing"
+
+func TestPatternMatcherNegationOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		path     string
+		want     bool
+	}{
+		{
+			name:     "no patterns match",
+			patterns: []string{"*.go"},
+			path:     "foo.txt",
+			want:     false,
+		},
+		{
+			name:     "plain match",
+			patterns: []string{"*.go"},
+			path:     "foo.go",
+			want:     true,
+		},
+		{
+			name:     "later negation cancels an earlier match",
+			patterns: []string{"*.go", "!foo.go"},
+			path:     "foo.go",
+			want:     false,
+		},
+		{
+			name:     "later non-negated pattern wins over an earlier negation",
+			patterns: []string{"*.go", "!foo.go", "foo.go"},
+			path:     "foo.go",
+			want:     true,
+		},
+		{
+			name:     "double negation cancels out",
+			patterns: []string{"*.go", "!!foo.go"},
+			path:     "foo.go",
+			want:     true,
+		},
+		{
+			name:     "negated pattern alone matches nothing",
+			patterns: []string{"!foo.go"},
+			path:     "foo.go",
+			want:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pm := NewPatternMatcher(tt.patterns)
+			got, err := pm.Matches(tt.path)
+			if err != nil {
+				t.Fatalf("Matches(%q) returned error: %v", tt.path, err)
+			}
+			if got != tt.want {
+				t.Errorf("Matches(%q) with patterns %v = %v, want %v", tt.path, tt.patterns, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatternMatcherRecursive(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		path    string
+		want    bool
+	}{
+		{
+			name:    "** in the middle matches any depth",
+			pattern: "foo/**/*.go",
+			path:    "foo/bar/baz/qux.go",
+			want:    true,
+		},
+		{
+			name:    "** in the middle matches zero path elements",
+			pattern: "foo/**/*.go",
+			path:    "foo/qux.go",
+			want:    true,
+		},
+		{
+			name: "trailing ** matches every descendant, unlike a trailing ** glob " +
+				"pattern which is rejected with GlobLastRecursiveErr",
+			pattern: "foo/**",
+			path:    "foo/bar/baz.go",
+			want:    true,
+		},
+		{
+			name:    "trailing ** does not match outside the prefix",
+			pattern: "foo/**",
+			path:    "bar/baz.go",
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pm := NewPatternMatcher([]string{tt.pattern})
+			got, err := pm.Matches(tt.path)
+			if err != nil {
+				t.Fatalf("Matches(%q) returned error: %v", tt.path, err)
+			}
+			if got != tt.want {
+				t.Errorf("Matches(%q) with pattern %q = %v, want %v", tt.path, tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatternMatcherMatchesOrParentMatches(t *testing.T) {
+	pm := NewPatternMatcher([]string{"vendor"})
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"vendor", true},
+		{"vendor/foo/bar.go", true},
+		{"src/vendor/foo.go", false},
+		{"src/foo.go", false},
+	}
+
+	for _, tt := range tests {
+		got, err := pm.MatchesOrParentMatches(tt.path)
+		if err != nil {
+			t.Fatalf("MatchesOrParentMatches(%q) returned error: %v", tt.path, err)
+		}
+		if got != tt.want {
+			t.Errorf("MatchesOrParentMatches(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pathtools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// GlobSymlinkCycleErr is returned by GlobWith when GlobOptions.FollowSymlinks
+// or GlobOptions.FollowPaths causes a recursive glob to follow a symlink
+// back to one of its own ancestor directories, rather than looping
+// indefinitely.
+var GlobSymlinkCycleErr = errors.New("glob: symlink cycle detected")
+
+// GlobOptions configures the optional symlink-following behavior of
+// GlobWith, on top of the defaults used by Glob and GlobWithExcludes.
+type GlobOptions struct {
+	// FollowSymlinks, when true, causes a recursive (**) glob to descend
+	// into symlinked directories instead of leaving them unvisited, which is
+	// the default because info.IsDir() is false for the symlink itself.
+	// A symlink that resolves back to one of its own ancestor directories is
+	// reported as GlobSymlinkCycleErr instead of causing an infinite walk;
+	// two symlinks that happen to point at the same directory without either
+	// being an ancestor of the other (for example two source trees
+	// symlinked to the same shared vendor tree) are not a cycle and are both
+	// walked normally.  FollowSymlinks has no effect if the Globber's FS does
+	// not implement SymlinkFS.
+	FollowSymlinks bool
+
+	// FollowPaths lists paths, relative to the same root the glob pattern is
+	// rooted at, whose symlink is followed if and when the walk reaches it,
+	// even if FollowSymlinks is false.  This lets a caller opt a single
+	// symlinked source tree (common in Android and Chromium vendor layouts)
+	// into globbing without following every symlink a pattern happens to
+	// encounter.  A path listed here that the walk never reaches has no
+	// effect; FollowPaths does not add extra search roots of its own.
+	FollowPaths []string
+}
+
+// GlobWith is equivalent to GlobWithExcludes, but accepts a GlobOptions to
+// control symlink-following behavior during a recursive (**) glob.
+func GlobWith(pattern string, opts GlobOptions) (matches, dirs []string, err error) {
+	return defaultGlobber.GlobWith(pattern, opts)
+}
+
+// GlobWith is equivalent to the package-level GlobWith function, but
+// searches g's FS instead of the real filesystem.
+func (g *Globber) GlobWith(pattern string, opts GlobOptions) (matches, dirs []string, err error) {
+	return g.globAll(context.Background(), pattern, nil, nil, opts)
+}
+
+// symlinkFollower builds the symlinkFollower concurrentWalkAllDirs uses to
+// decide whether, and to where, to descend into symlinked directories, or
+// nil if opts doesn't require following any symlinks or g's FS can't resolve
+// them.
+func (g *Globber) symlinkFollower(opts GlobOptions) *symlinkFollower {
+	if !opts.FollowSymlinks && len(opts.FollowPaths) == 0 {
+		return nil
+	}
+
+	sfs, ok := g.fs.(SymlinkFS)
+	if !ok {
+		return nil
+	}
+
+	followPaths := make(map[string]bool, len(opts.FollowPaths))
+	for _, p := range opts.FollowPaths {
+		followPaths[filepath.Clean(p)] = true
+	}
+
+	return &symlinkFollower{
+		shouldFollow: func(path string, entry os.FileInfo) (bool, error) {
+			return opts.FollowSymlinks || followPaths[filepath.Clean(path)], nil
+		},
+		dirKey: func(path string) (key interface{}, isDir bool, err error) {
+			real, err := sfs.EvalSymlinks(path)
+			if err != nil {
+				return nil, false, err
+			}
+
+			info, err := g.fs.Stat(real)
+			if err != nil {
+				return nil, false, err
+			}
+			if !info.IsDir() {
+				return nil, false, nil
+			}
+
+			if k, ok := fileKey(info); ok {
+				return k, true, nil
+			}
+			return real, true, nil
+		},
+	}
+}
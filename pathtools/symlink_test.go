@@ -0,0 +1,97 @@
+// Copyright 2014 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pathtools
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// MemFS does not model symlinks, so these tests exercise OsFS against a real
+// directory tree instead, which is the only FS in this package that
+// implements SymlinkFS.
+
+func TestGlobWithFollowSymlinksDiamondIsNotACycle(t *testing.T) {
+	root := t.TempDir()
+
+	vendor := filepath.Join(root, "vendor")
+	if err := os.MkdirAll(vendor, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(vendor, "file.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srcA := filepath.Join(root, "srcA")
+	srcB := filepath.Join(root, "srcB")
+	if err := os.MkdirAll(srcA, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(srcB, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two unrelated source trees symlinked to the same shared vendor tree,
+	// the Android/Chromium vendor layout this feature targets.  Neither
+	// symlink is an ancestor of the other, so this is not a cycle.
+	if err := os.Symlink(vendor, filepath.Join(srcA, "vendor")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(vendor, filepath.Join(srcB, "vendor")); err != nil {
+		t.Fatal(err)
+	}
+
+	matches, _, err := defaultGlobber.GlobWith(filepath.Join(root, "**/*.txt"), GlobOptions{FollowSymlinks: true})
+	if err != nil {
+		t.Fatalf("GlobWith returned error: %v", err)
+	}
+
+	want := []string{
+		filepath.Join(srcA, "vendor", "file.txt"),
+		filepath.Join(srcB, "vendor", "file.txt"),
+		filepath.Join(vendor, "file.txt"),
+	}
+	sort.Strings(want)
+
+	if len(matches) != len(want) {
+		t.Fatalf("GlobWith matches = %v, want %v", matches, want)
+	}
+	for i := range want {
+		if matches[i] != want[i] {
+			t.Fatalf("GlobWith matches = %v, want %v", matches, want)
+		}
+	}
+}
+
+func TestGlobWithFollowSymlinksRealCycleErrors(t *testing.T) {
+	root := t.TempDir()
+
+	sub := filepath.Join(root, "sub")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A symlink back to one of its own ancestors is a genuine cycle.
+	if err := os.Symlink(root, filepath.Join(sub, "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err := defaultGlobber.GlobWith(filepath.Join(root, "**/*"), GlobOptions{FollowSymlinks: true})
+	if err != GlobSymlinkCycleErr {
+		t.Fatalf("GlobWith returned error %v, want %v", err, GlobSymlinkCycleErr)
+	}
+}